@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/kkzliu/yee"
+)
+
+// CSRFConfig defines the config of CSRF middleware
+type CSRFConfig struct {
+	// Skipper defines a function to skip middleware, routes matched by it
+	// won't be protected against CSRF.
+	Skipper func(yee.Context) bool
+
+	// TokenLength is the number of random bytes in a generated token; the
+	// token itself is their unpadded base64url encoding.
+	TokenLength int
+
+	// TokenLookup is a comma-separated list of "source:name" pairs used to
+	// extract the token from the request on unsafe methods, e.g.
+	// "header:X-CSRF-Token,form:_csrf,query:csrf".
+	TokenLookup string
+
+	// ContextKey is the key under which the token is stored via c.Put so
+	// templates can render it into forms.
+	ContextKey string
+
+	CookieName     string
+	CookieDomain   string
+	CookiePath     string
+	CookieMaxAge   int
+	CookieSecure   bool
+	CookieHTTPOnly bool
+	CookieSameSite http.SameSite
+}
+
+type csrfExtractor func(yee.Context) (string, error)
+
+const defaultCSRFTokenLength = 32
+
+// DefaultCSRFConfig is the default config of CSRF middleware
+var DefaultCSRFConfig = CSRFConfig{
+	TokenLength:  defaultCSRFTokenLength,
+	TokenLookup:  "header:X-CSRF-Token",
+	ContextKey:   "csrf",
+	CookieName:   "_csrf",
+	CookiePath:   "/",
+	CookieMaxAge: 86400,
+}
+
+// CSRF returns a CSRF middleware using the default config, implementing the
+// double-submit-cookie pattern.
+func CSRF() yee.HandlerFunc {
+	return CSRFWithConfig(DefaultCSRFConfig)
+}
+
+// CSRFWithConfig is the custom implementation of CSRF middleware
+func CSRFWithConfig(config CSRFConfig) yee.HandlerFunc {
+	if config.TokenLength == 0 {
+		config.TokenLength = DefaultCSRFConfig.TokenLength
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultCSRFConfig.TokenLookup
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultCSRFConfig.ContextKey
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCSRFConfig.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultCSRFConfig.CookiePath
+	}
+	if config.CookieMaxAge == 0 {
+		config.CookieMaxAge = DefaultCSRFConfig.CookieMaxAge
+	}
+
+	extractors := make([]csrfExtractor, 0)
+	for _, lookup := range strings.Split(config.TokenLookup, ",") {
+		parts := strings.Split(strings.TrimSpace(lookup), ":")
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "header":
+			extractors = append(extractors, csrfFromHeader(parts[1]))
+		case "form":
+			extractors = append(extractors, csrfFromForm(parts[1]))
+		case "query":
+			extractors = append(extractors, csrfFromQuery(parts[1]))
+		}
+	}
+
+	return func(c yee.Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return nil
+		}
+
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			token := ""
+			if cookie, err := c.Cookie(config.CookieName); err == nil {
+				token = cookie.Value
+			}
+			if token == "" {
+				generated, err := generateCSRFToken(config.TokenLength)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, err.Error())
+				}
+				token = generated
+			}
+			c.SetCookie(&http.Cookie{
+				Name:     config.CookieName,
+				Value:    token,
+				Domain:   config.CookieDomain,
+				Path:     config.CookiePath,
+				MaxAge:   config.CookieMaxAge,
+				Secure:   config.CookieSecure,
+				HttpOnly: config.CookieHTTPOnly,
+				SameSite: config.CookieSameSite,
+			})
+			c.Put(config.ContextKey, token)
+			return nil
+		default:
+			cookie, err := c.Cookie(config.CookieName)
+			if err != nil || cookie.Value == "" {
+				return c.JSON(http.StatusForbidden, "missing csrf cookie")
+			}
+
+			var clientToken string
+			for _, extract := range extractors {
+				clientToken, err = extract(c)
+				if err == nil {
+					break
+				}
+			}
+			if err != nil {
+				return c.JSON(http.StatusForbidden, "missing csrf token")
+			}
+
+			if subtle.ConstantTimeCompare([]byte(clientToken), []byte(cookie.Value)) != 1 {
+				return c.JSON(http.StatusForbidden, "invalid csrf token")
+			}
+
+			c.Put(config.ContextKey, cookie.Value)
+			return nil
+		}
+	}
+}
+
+func generateCSRFToken(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func csrfFromHeader(header string) csrfExtractor {
+	return func(c yee.Context) (string, error) {
+		token := c.Request().Header.Get(header)
+		if token == "" {
+			return "", errors.New("missing csrf token in header")
+		}
+		return token, nil
+	}
+}
+
+func csrfFromForm(param string) csrfExtractor {
+	return func(c yee.Context) (string, error) {
+		token := c.FormValue(param)
+		if token == "" {
+			return "", errors.New("missing csrf token in form")
+		}
+		return token, nil
+	}
+}
+
+func csrfFromQuery(param string) csrfExtractor {
+	return func(c yee.Context) (string, error) {
+		token := c.QueryParam(param)
+		if token == "" {
+			return "", errors.New("missing csrf token in query")
+		}
+		return token, nil
+	}
+}
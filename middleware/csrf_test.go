@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kkzliu/yee"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFGetSetsCookie(t *testing.T) {
+	y := yee.New()
+	y.Use(CSRF())
+	y.GET("/form", func(c yee.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	rec := httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, DefaultCSRFConfig.CookieName, cookies[0].Name)
+	assert.NotEmpty(t, cookies[0].Value)
+}
+
+func TestCSRFGetReusesExistingCookie(t *testing.T) {
+	y := yee.New()
+	y.Use(CSRF())
+	y.GET("/form", func(c yee.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	rec := httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	first := rec.Result().Cookies()[0]
+
+	req = httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(first)
+	rec = httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	second := rec.Result().Cookies()[0]
+
+	assert.Equal(t, first.Value, second.Value)
+}
+
+func TestCSRFPostValidatesToken(t *testing.T) {
+	y := yee.New()
+	y.Use(CSRF())
+	y.GET("/form", func(c yee.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	y.POST("/submit", func(c yee.Context) error {
+		return c.String(http.StatusOK, "submitted")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	rec := httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	cookie := rec.Result().Cookies()[0]
+
+	t.Run("matching_token_accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(cookie)
+		req.Header.Set("X-CSRF-Token", cookie.Value)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("mismatched_token_rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(cookie)
+		req.Header.Set("X-CSRF-Token", "wrong-token")
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("missing_cookie_rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.Header.Set("X-CSRF-Token", cookie.Value)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("missing_token_rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
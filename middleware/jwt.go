@@ -2,10 +2,10 @@ package middleware
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/kkzliu/yee"
 	"github.com/dgrijalva/jwt-go"
@@ -13,12 +13,38 @@ import (
 
 // JwtConfig defines the config of JWT middleware
 type JwtConfig struct {
-	GetKey         string
-	AuthScheme     string
-	SigningKey     interface{}
-	SigningMethod  string
-	TokenLookup    string
-	Claims         jwt.Claims
+	// Skipper defines a function to skip middleware, routes matched by it
+	// won't require a valid jwt to pass through.
+	Skipper       func(yee.Context) bool
+	GetKey        string
+	AuthScheme    string
+	SigningKey    interface{}
+	SigningMethod string
+	TokenLookup   string
+	Claims        jwt.Claims
+
+	// SigningKeys holds additional verification keys keyed by the JWT "kid"
+	// header, for applications that rotate keys without a JWKS endpoint.
+	SigningKeys map[string]interface{}
+
+	// KeyFunc is an escape hatch that bypasses SigningKey/SigningKeys/JWKSUrl
+	// entirely and resolves the verification key however the caller wants.
+	KeyFunc jwt.Keyfunc
+
+	// JWKSUrl, when set, is periodically fetched to resolve verification
+	// keys by "kid" instead of a static SigningKey.
+	JWKSUrl string
+
+	// JWKSRefreshInterval controls how often JWKSUrl is re-fetched.
+	// Defaults to DefaultJWKSRefreshInterval.
+	JWKSRefreshInterval time.Duration
+
+	// Revoker is consulted after a token passes signature/claims validation,
+	// letting applications invalidate tokens before their natural expiry
+	// (e.g. on logout or once a token is known to be compromised). A true
+	// return fails the request with 401 "token revoked".
+	Revoker func(yee.Context, *jwt.Token) (bool, error)
+
 	keyFunc        jwt.Keyfunc
 	ErrorHandler   JWTErrorHandler
 	SuccessHandler JWTSuccessHandler
@@ -26,8 +52,14 @@ type JwtConfig struct {
 
 type jwtExtractor func(yee.Context) (string, error)
 
-// JWTErrorHandler defines a function which is error for a valid token.
-type JWTErrorHandler func(error) error
+// JWTErrorHandler defines a function which is called when token extraction
+// or validation fails, letting the application take over the response. The
+// middleware chain only stops once a response has actually been written, so
+// an ErrorHandler that returns an error without writing one (e.g. just
+// logging and returning it) would not block the request on its own.
+// JWTWithConfig therefore falls back to the default 400/401 JSON response
+// whenever ErrorHandler returns without writing.
+type JWTErrorHandler func(yee.Context, error) error
 
 // JWTSuccessHandler defines a function which is executed for a valid token.
 type JWTSuccessHandler func(yee.Context)
@@ -45,7 +77,7 @@ var DefaultJwtConfig = JwtConfig{
 
 // JWTWithConfig is the custom implementation CORS middleware
 func JWTWithConfig(config JwtConfig) yee.HandlerFunc {
-	if config.SigningKey == nil {
+	if config.SigningKey == nil && config.SigningKeys == nil && config.KeyFunc == nil && config.JWKSUrl == "" {
 		panic("yee: jwt middleware requires signing key")
 	}
 	if config.SigningMethod == "" {
@@ -66,41 +98,85 @@ func JWTWithConfig(config JwtConfig) yee.HandlerFunc {
 		config.TokenLookup = DefaultJwtConfig.TokenLookup
 	}
 
-	config.keyFunc = func(token *jwt.Token) (interface{}, error) {
-		if token.Method.Alg() != config.SigningMethod {
-			return nil, fmt.Errorf("unexpected jwt signing method=%v", token.Header["alg"])
+	config.keyFunc = config.buildKeyFunc()
+
+	extractors := make([]jwtExtractor, 0)
+	for _, lookup := range strings.Split(config.TokenLookup, ",") {
+		parts := strings.Split(strings.TrimSpace(lookup), ":")
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "header":
+			extractors = append(extractors, jwtFromHeader(parts[1], config.AuthScheme))
+		case "query":
+			extractors = append(extractors, jwtFromQuery(parts[1]))
+		case "cookie":
+			extractors = append(extractors, jwtFromCookie(parts[1]))
+		case "form":
+			extractors = append(extractors, jwtFromForm(parts[1]))
 		}
-		return config.SigningKey, nil
 	}
 
-	parts := strings.Split(config.TokenLookup, ":")
-	extractor := jwtFromHeader(parts[1], config.AuthScheme)
-
 	return func(c yee.Context) (err error) {
-		auth, err := extractor(c)
+		if config.Skipper != nil && config.Skipper(c) {
+			return nil
+		}
+		var auth string
+		for _, extract := range extractors {
+			auth, err = extract(c)
+			if err == nil {
+				break
+			}
+		}
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, err.Error())
+			return handleJWTError(c, config.ErrorHandler, err, http.StatusBadRequest, err.Error())
 		}
 		token := new(jwt.Token)
 		if _, ok := config.Claims.(jwt.MapClaims); ok {
 			token, err = jwt.Parse(auth, config.keyFunc)
-			if err != nil {
-				return c.JSON(http.StatusUnauthorized, err.Error())
-			}
 		} else {
 			t := reflect.ValueOf(config.Claims).Type().Elem()
 			claims := reflect.New(t).Interface().(jwt.Claims)
 			token, err = jwt.ParseWithClaims(auth, claims, config.keyFunc)
 		}
 		if err == nil && token.Valid {
+			if config.Revoker != nil {
+				revoked, rerr := config.Revoker(c, token)
+				if rerr != nil {
+					return handleJWTError(c, config.ErrorHandler, rerr, http.StatusUnauthorized, rerr.Error())
+				}
+				if revoked {
+					err = errors.New("token revoked")
+					return handleJWTError(c, config.ErrorHandler, err, http.StatusUnauthorized, err.Error())
+				}
+			}
 			c.Put(config.GetKey, token)
-			return
+			if config.SuccessHandler != nil {
+				config.SuccessHandler(c)
+			}
+			return nil
 		}
 		// bug fix
 		// if  invalid or expired jwt,
 		// we must intercept all handlers and return serverError
-		return c.JSON(http.StatusUnauthorized, "invalid or expired jwt")
+		return handleJWTError(c, config.ErrorHandler, err, http.StatusUnauthorized, "invalid or expired jwt")
+	}
+}
+
+// handleJWTError invokes handler when set, then fails closed: the yee
+// dispatch loop only halts the middleware chain once a response has been
+// written, so a handler that returns without writing one must not be
+// trusted to have blocked the request.
+func handleJWTError(c yee.Context, handler JWTErrorHandler, err error, defaultStatus int, defaultBody interface{}) error {
+	if handler == nil {
+		return c.JSON(defaultStatus, defaultBody)
+	}
+	herr := handler(c, err)
+	if c.Response().Written() {
+		return herr
 	}
+	return c.JSON(defaultStatus, defaultBody)
 }
 
 func jwtFromHeader(header string, authScheme string) jwtExtractor {
@@ -113,3 +189,33 @@ func jwtFromHeader(header string, authScheme string) jwtExtractor {
 		return "", errors.New("missing or malformed jwt")
 	}
 }
+
+func jwtFromQuery(param string) jwtExtractor {
+	return func(c yee.Context) (string, error) {
+		token := c.QueryParam(param)
+		if token == "" {
+			return "", errors.New("missing or malformed jwt")
+		}
+		return token, nil
+	}
+}
+
+func jwtFromCookie(name string) jwtExtractor {
+	return func(c yee.Context) (string, error) {
+		cookie, err := c.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", errors.New("missing or malformed jwt")
+		}
+		return cookie.Value, nil
+	}
+}
+
+func jwtFromForm(param string) jwtExtractor {
+	return func(c yee.Context) (string, error) {
+		token := c.FormValue(param)
+		if token == "" {
+			return "", errors.New("missing or malformed jwt")
+		}
+		return token, nil
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// DefaultJWKSRefreshInterval is used when JwtConfig.JWKSRefreshInterval is zero.
+const DefaultJWKSRefreshInterval = time.Hour
+
+// jwksFetchTimeout bounds every JWKS HTTP request so a slow or unreachable
+// identity provider can never hang route setup or a refresh cycle.
+const jwksFetchTimeout = 5 * time.Second
+
+// buildKeyFunc resolves config.KeyFunc/JWKSUrl/SigningKeys/SigningKey, in
+// that order of precedence, into the jwt.Keyfunc used to verify tokens.
+func (config JwtConfig) buildKeyFunc() jwt.Keyfunc {
+	if config.KeyFunc != nil {
+		return config.KeyFunc
+	}
+
+	if config.JWKSUrl != "" {
+		fetcher := newJWKSFetcher(config.JWKSUrl, config.JWKSRefreshInterval)
+		return func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			if key, ok := fetcher.key(kid); ok {
+				return key, nil
+			}
+			return nil, fmt.Errorf("unable to find jwks key for kid=%v", kid)
+		}
+	}
+
+	return func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != config.SigningMethod {
+			return nil, fmt.Errorf("unexpected jwt signing method=%v", token.Header["alg"])
+		}
+		if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+			if key, ok := config.SigningKeys[kid]; ok {
+				return key, nil
+			}
+		}
+		return config.SigningKey, nil
+	}
+}
+
+// jwksFetcher periodically refreshes a JWKS document in the background and
+// resolves RSA verification keys by "kid", for providers such as Auth0,
+// Azure AD and Cognito that rotate signing keys without notice.
+type jwksFetcher struct {
+	url    string
+	client *http.Client
+	stop   chan struct{}
+
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func newJWKSFetcher(url string, interval time.Duration) *jwksFetcher {
+	if interval <= 0 {
+		interval = DefaultJWKSRefreshInterval
+	}
+	f := &jwksFetcher{
+		url:    url,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+		stop:   make(chan struct{}),
+		keys:   map[string]interface{}{},
+	}
+	// The first fetch also runs in the background so that a slow or
+	// unreachable JWKS endpoint can never block middleware setup; key()
+	// simply misses until it completes.
+	go f.loop(interval)
+	// The only reference to f is the keyFunc closure in buildKeyFunc, so
+	// once that closure (and the middleware holding it) is unreachable,
+	// stop the refresh goroutine instead of leaking it for good.
+	runtime.SetFinalizer(f, (*jwksFetcher).Close)
+	return f
+}
+
+func (f *jwksFetcher) loop(interval time.Duration) {
+	f.refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.refresh()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background refresh goroutine. It runs automatically via
+// a finalizer once the fetcher is garbage collected, but callers that build
+// and discard many JWTWithConfig middlewares (e.g. tests, config reloads)
+// may call it directly to release the goroutine eagerly.
+func (f *jwksFetcher) Close() {
+	select {
+	case <-f.stop:
+	default:
+		close(f.stop)
+	}
+}
+
+func (f *jwksFetcher) refresh() {
+	resp, err := f.client.Get(f.url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		pub, err := k.rsaPublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	f.mu.Lock()
+	f.keys = keys
+	f.mu.Unlock()
+}
+
+func (f *jwksFetcher) key(kid string) (interface{}, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	key, ok := f.keys[kid]
+	return key, ok
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 + int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
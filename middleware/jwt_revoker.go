@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kkzliu/yee"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// BlacklistStore tracks revoked JWT ids (the "jti" claim), for applications
+// that want revocation backed by something other than the default in-memory
+// map (Redis, a database, ...).
+type BlacklistStore interface {
+	IsRevoked(jti string) bool
+	Revoke(jti string, expiresAt time.Time)
+}
+
+// MemoryRevoker is a BlacklistStore backed by an in-memory map. Entries are
+// dropped once the token they belong to would have expired anyway, so the
+// store never grows without bound.
+type MemoryRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevoker returns a BlacklistStore backed by an in-memory map.
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{revoked: map[string]time.Time{}}
+}
+
+// IsRevoked reports whether jti has been revoked and has not yet expired.
+func (m *MemoryRevoker) IsRevoked(jti string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt, ok := m.revoked[jti]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		delete(m.revoked, jti)
+		return false
+	}
+	return true
+}
+
+// Revoke marks jti as revoked until expiresAt.
+func (m *MemoryRevoker) Revoke(jti string, expiresAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, exp := range m.revoked {
+		if now.After(exp) {
+			delete(m.revoked, id)
+		}
+	}
+	m.revoked[jti] = expiresAt
+}
+
+// RevokerFromStore adapts a BlacklistStore into the Revoker func expected by
+// JwtConfig, looking the token's "jti" claim up in store.
+func RevokerFromStore(store BlacklistStore) func(yee.Context, *jwt.Token) (bool, error) {
+	return func(_ yee.Context, token *jwt.Token) (bool, error) {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return false, nil
+		}
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			return false, nil
+		}
+		return store.IsRevoked(jti), nil
+	}
+}
+
+// RevokeToken revokes the "jti" claim of token in store. token must already
+// be signature-verified — e.g. the *jwt.Token the JWT middleware itself
+// stored via config.GetKey, which RevokeToken(store, c.Get(config.GetKey).(*jwt.Token))
+// retrieves on a logout route. It deliberately does not accept a raw token
+// string: doing so would let anyone who merely knows another user's jti (no
+// proof of possession of a validly-signed token) force-revoke that session.
+func RevokeToken(store BlacklistStore, token *jwt.Token) error {
+	if !token.Valid {
+		return errors.New("cannot revoke an unverified token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return errors.New("token has no jti claim to revoke")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("token has no jti claim to revoke")
+	}
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+
+	store.Revoke(jti, expiresAt)
+	return nil
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kkzliu/yee"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const jwtTestSecret = "secret"
+
+func newJWTTestToken(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(jwtTestSecret))
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestJWTTokenLookupFallthrough(t *testing.T) {
+	token := newJWTTestToken(t, jwt.MapClaims{"sub": "user1"})
+
+	y := yee.New()
+	y.Use(JWTWithConfig(JwtConfig{
+		SigningKey:  []byte(jwtTestSecret),
+		TokenLookup: "header:Authorization,query:jwt,cookie:session,form:access_token",
+	}))
+	y.GET("/secure", func(c yee.Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	t.Run("from_header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		req.Header.Set(yee.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("from_query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/secure?jwt="+token, nil)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("from_cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: token})
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("none_present", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestJWTSkipper(t *testing.T) {
+	y := yee.New()
+	y.Use(JWTWithConfig(JwtConfig{
+		SigningKey: []byte(jwtTestSecret),
+		Skipper: func(c yee.Context) bool {
+			return c.Request().URL.Path == "/health"
+		},
+	}))
+	y.GET("/health", func(c yee.Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestJWKSFetcherCloseStopsRefreshLoop(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_ = json.NewEncoder(w).Encode(jwkSet{})
+	}))
+	defer server.Close()
+
+	f := newJWKSFetcher(server.URL, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&hits) > 0 // confirms at least one refresh ran
+	}, time.Second, 10*time.Millisecond)
+
+	f.Close()
+	f.Close() // must not panic on a second call
+
+	// Let a refresh already in flight finish, then no further ones may follow.
+	time.Sleep(50 * time.Millisecond)
+	stopped := atomic.LoadInt32(&hits)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&hits))
+}
+
+func TestJWTWithJWKS(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: "key-1",
+			N:   base64.RawURLEncoding.EncodeToString(priv.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1}),
+		}}})
+	}))
+	defer server.Close()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user1"})
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(priv)
+	assert.NoError(t, err)
+
+	y := yee.New()
+	y.Use(JWTWithConfig(JwtConfig{
+		SigningMethod:       "RS256",
+		JWKSUrl:             server.URL,
+		JWKSRefreshInterval: time.Hour,
+	}))
+	y.GET("/secure", func(c yee.Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	t.Run("known_kid", func(t *testing.T) {
+		// The first JWKS fetch happens in the background (see
+		// newJWKSFetcher), so the key may not be resolvable yet.
+		assert.Eventually(t, func() bool {
+			req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+			req.Header.Set(yee.HeaderAuthorization, "Bearer "+signed)
+			rec := httptest.NewRecorder()
+			y.ServeHTTP(rec, req)
+			return rec.Code == http.StatusOK
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("unknown_kid", func(t *testing.T) {
+		other := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user2"})
+		other.Header["kid"] = "unknown"
+		otherSigned, err := other.SignedString(priv)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		req.Header.Set(yee.HeaderAuthorization, "Bearer "+otherSigned)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestJWTErrorAndSuccessHandlers(t *testing.T) {
+	var successCalled bool
+	var handledErr error
+
+	y := yee.New()
+	y.Use(JWTWithConfig(JwtConfig{
+		SigningKey: []byte(jwtTestSecret),
+		ErrorHandler: func(c yee.Context, err error) error {
+			handledErr = err
+			return c.JSON(http.StatusTeapot, "custom error")
+		},
+		SuccessHandler: func(c yee.Context) {
+			successCalled = true
+		},
+	}))
+	y.GET("/secure", func(c yee.Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	t.Run("success_handler_invoked", func(t *testing.T) {
+		token := newJWTTestToken(t, jwt.MapClaims{"sub": "user1"})
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		req.Header.Set(yee.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, successCalled)
+	})
+
+	t.Run("error_handler_invoked", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		rec := httptest.NewRecorder()
+		y.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+		assert.Error(t, handledErr)
+	})
+}
+
+func TestJWTErrorHandlerFailsClosedWithoutWrite(t *testing.T) {
+	y := yee.New()
+	y.Use(JWTWithConfig(JwtConfig{
+		SigningKey: []byte(jwtTestSecret),
+		ErrorHandler: func(c yee.Context, err error) error {
+			// Deliberately doesn't write a response - must not bypass auth.
+			return err
+		},
+	}))
+	y.GET("/secure", func(c yee.Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+	rec := httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestJWTRevoker(t *testing.T) {
+	store := NewMemoryRevoker()
+
+	y := yee.New()
+	y.Use(JWTWithConfig(JwtConfig{
+		SigningKey: []byte(jwtTestSecret),
+		GetKey:     "auth",
+		Revoker:    RevokerFromStore(store),
+	}))
+	y.GET("/secure", func(c yee.Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	token := newJWTTestToken(t, jwt.MapClaims{"sub": "user1", "jti": "abc123"})
+
+	req := httptest.NewRequest(http.MethodGet, "/secure", nil)
+	req.Header.Set(yee.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	store.Revoke("abc123", time.Now().Add(time.Hour))
+
+	req = httptest.NewRequest(http.MethodGet, "/secure", nil)
+	req.Header.Set(yee.HeaderAuthorization, "Bearer "+token)
+	rec = httptest.NewRecorder()
+	y.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRevokeTokenRejectsUnverified(t *testing.T) {
+	store := NewMemoryRevoker()
+	err := RevokeToken(store, &jwt.Token{Valid: false})
+	assert.Error(t, err)
+}